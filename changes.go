@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// startChangesSyncLoop runs the incremental sync loop: the first cycle (when no page token has
+// been persisted yet) does a full recursive walk to seed the local mirror, path index and SHA
+// cache, then every later cycle pulls the Drive Changes feed and applies it directly instead of
+// re-listing the whole tree.
+func startChangesSyncLoop(ctx context.Context, driveAPI DriveAPI, folderName string, exportExts map[string]bool, parallelDownloads int, pruneOpts pruneOptions) {
+	state, err := loadState()
+	if err != nil {
+		log.Fatalf("Unable to load sync state: %v", err)
+	}
+
+	if state.PageToken == "" {
+		fmt.Println("\nNo persisted page token found; performing initial full walk.")
+		if _, err := performSync(ctx, driveAPI, folderName, time.Time{}, state.ShaCache, exportExts, state.PathIndex, parallelDownloads, pruneOpts); err != nil {
+			log.Fatalf("Initial walk failed: %v", err)
+		}
+		token, err := driveAPI.GetStartPageToken(ctx)
+		if err != nil {
+			log.Fatalf("Unable to get start page token: %v", err)
+		}
+		state.PageToken = token
+		if err := state.save(); err != nil {
+			log.Printf("Failed to persist sync state: %v", err)
+		}
+	}
+
+	rootFolderID, err := driveAPI.GetFolderID(ctx, folderName)
+	if err != nil {
+		log.Fatalf("Error finding folder: %v", err)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Sync loop shutting down.")
+			return
+		default:
+			fmt.Printf("\nPolling for changes...\n")
+			if pruneOpts.useTrash {
+				if err := sweepTrash(pruneOpts.trashRetention); err != nil {
+					log.Printf("Failed to sweep expired trash: %v", err)
+				}
+			}
+			if err := performChangesSync(ctx, driveAPI, rootFolderID, state, exportExts, pruneOpts); err != nil {
+				log.Printf("Changes sync cycle failed: %v. Retrying in %v.", err, syncInterval)
+			} else if err := state.save(); err != nil {
+				log.Printf("Failed to persist sync state: %v", err)
+			}
+			time.Sleep(syncInterval)
+		}
+	}
+}
+
+// performChangesSync pages through the Drive Changes feed starting at state.PageToken, applies
+// each change to the local mirror, and advances state.PageToken to the new start token once the
+// feed is caught up.
+func performChangesSync(ctx context.Context, driveAPI DriveAPI, rootFolderID string, state *syncState, exportExts map[string]bool, pruneOpts pruneOptions) error {
+	pageToken := state.PageToken
+	for {
+		changes, nextPageToken, newStartPageToken, err := driveAPI.ListChanges(ctx, pageToken)
+		if err != nil {
+			return fmt.Errorf("error listing changes: %w", err)
+		}
+
+		for _, change := range changes {
+			applyChange(ctx, driveAPI, rootFolderID, change, state, exportExts, pruneOpts)
+		}
+
+		if nextPageToken == "" {
+			state.PageToken = newStartPageToken
+			return nil
+		}
+		pageToken = nextPageToken
+	}
+}
+
+// applyChange brings the local mirror in line with a single Changes feed entry: removing the
+// local copy of a file that was deleted, trashed, or moved out from under the watched folder, or
+// resolving its current path and downloading it otherwise.
+func applyChange(ctx context.Context, driveAPI DriveAPI, rootFolderID string, change *drive.Change, state *syncState, exportExts map[string]bool, pruneOpts pruneOptions) {
+	if change.Removed || (change.File != nil && change.File.Trashed) {
+		removeTrackedFile(change.FileId, state, pruneOpts)
+		return
+	}
+
+	file := change.File
+	if file == nil {
+		return
+	}
+
+	localPath, underRoot, err := resolvePath(ctx, driveAPI, rootFolderID, file, state.PathIndex)
+	if err != nil {
+		log.Printf("Failed to resolve path for %s: %v", file.Name, err)
+		return
+	}
+	if !underRoot {
+		removeTrackedFile(file.Id, state, pruneOpts)
+		return
+	}
+
+	// Resolve the export format (if any) up front so oldPath is compared against the same
+	// export-aware path that was recorded in state.PathIndex, rather than the bare Drive name:
+	// otherwise re-syncing an unchanged Workspace doc would look like a move every time.
+	var export *exportFormat
+	isWorkspaceDoc := file.MimeType != "application/vnd.google-apps.folder" && strings.HasPrefix(file.MimeType, "application/vnd.google-apps.")
+	if isWorkspaceDoc {
+		format, ok := resolveExportFormat(file.MimeType, exportExts)
+		if !ok {
+			log.Printf("Skipping Google Workspace file: %s", file.Name)
+			return
+		}
+		export = &format
+	}
+	finalPath := exportedPath(file, filepath.Dir(localPath), export)
+
+	if oldPath, tracked := state.PathIndex[file.Id]; tracked && oldPath != finalPath {
+		fmt.Printf("Moving '%s' -> '%s'\n", oldPath, finalPath)
+		if err := os.Rename(oldPath, finalPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to move %s to %s: %v", oldPath, finalPath, err)
+		}
+		delete(state.ShaCache, oldPath)
+	}
+
+	if file.MimeType == "application/vnd.google-apps.folder" {
+		if err := os.MkdirAll(finalPath, 0755); err != nil {
+			log.Printf("Failed to create directory %s: %v", finalPath, err)
+			return
+		}
+		state.PathIndex[file.Id] = finalPath
+		return
+	}
+
+	dir := filepath.Dir(finalPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("Failed to create directory %s: %v", dir, err)
+		return
+	}
+
+	downloadFile(driveAPI, file, dir, state.ShaCache, export)
+	state.PathIndex[file.Id] = finalPath
+}
+
+// removeTrackedFile deletes (or, with pruneOpts.useTrash, soft-deletes) the local copy of a
+// previously-tracked file, if any, and clears its bookkeeping entries.
+func removeTrackedFile(fileID string, state *syncState, pruneOpts pruneOptions) {
+	localPath, ok := state.PathIndex[fileID]
+	if !ok {
+		return
+	}
+
+	if pruneOpts.useTrash {
+		fmt.Printf("Trashing '%s'\n", localPath)
+		timestamp := time.Now().UTC().Format(trashTimestampFormat)
+		if err := moveToTrash(localPath, timestamp, state.ShaCache[localPath]); err != nil {
+			log.Printf("Failed to trash %s: %v", localPath, err)
+		}
+	} else {
+		fmt.Printf("Removing '%s'\n", localPath)
+		if err := os.RemoveAll(localPath); err != nil {
+			log.Printf("Failed to remove %s: %v", localPath, err)
+		}
+	}
+	delete(state.PathIndex, fileID)
+	delete(state.ShaCache, localPath)
+}
+
+// resolvePath computes the local path a changed file maps to by walking its parents up to
+// rootFolderID, using pathIndex to short-circuit as soon as a known ancestor is found. It
+// returns underRoot=false if the walk runs out of parents before reaching the root, meaning the
+// file is no longer (or never was) inside the watched folder.
+func resolvePath(ctx context.Context, driveAPI DriveAPI, rootFolderID string, file *drive.File, pathIndex map[string]string) (string, bool, error) {
+	names := []string{file.Name}
+	parents := file.Parents
+
+	for {
+		if len(parents) == 0 {
+			return "", false, nil
+		}
+		parentID := parents[0]
+
+		if parentID == rootFolderID {
+			return joinNames(downloadDir, names), true, nil
+		}
+		if parentPath, ok := pathIndex[parentID]; ok {
+			return joinNames(parentPath, names), true, nil
+		}
+
+		parent, err := driveAPI.GetFile(ctx, parentID)
+		if err != nil {
+			return "", false, err
+		}
+		names = append(names, parent.Name)
+		parents = parent.Parents
+	}
+}
+
+// joinNames appends names (collected from innermost to outermost while walking up the parent
+// chain) onto base in outermost-to-innermost order.
+func joinNames(base string, names []string) string {
+	path := base
+	for i := len(names) - 1; i >= 0; i-- {
+		path = filepath.Join(path, names[i])
+	}
+	return path
+}
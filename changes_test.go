@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// mockChangesAPI is a DriveAPI mock focused on the Changes feed: it serves GetFile lookups from
+// filesByID (used to walk a changed file's ancestors) and returns one page of changes per call.
+type mockChangesAPI struct {
+	filesByID   map[string]*drive.File
+	fileContent map[string]string
+	folders     map[string]string
+	changes     []*drive.Change
+}
+
+func (m *mockChangesAPI) ListFiles(ctx context.Context, query string) ([]*drive.File, error) {
+	return nil, nil
+}
+
+func (m *mockChangesAPI) DownloadFile(fileID string) (io.ReadCloser, error) {
+	content, ok := m.fileContent[fileID]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+func (m *mockChangesAPI) ExportFile(fileID, mimeType string) (io.ReadCloser, error) {
+	return m.DownloadFile(fileID)
+}
+
+func (m *mockChangesAPI) GetFolderID(ctx context.Context, name string) (string, error) {
+	return m.folders[name], nil
+}
+
+func (m *mockChangesAPI) GetFile(ctx context.Context, fileID string) (*drive.File, error) {
+	file, ok := m.filesByID[fileID]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return file, nil
+}
+
+func (m *mockChangesAPI) GetStartPageToken(ctx context.Context) (string, error) {
+	return "start-token", nil
+}
+
+func (m *mockChangesAPI) ListChanges(ctx context.Context, pageToken string) ([]*drive.Change, string, string, error) {
+	return m.changes, "", "next-token", nil
+}
+
+// TestApplyChangeDownloadsAndRemoves verifies that a changed file is downloaded under its
+// resolved path and that a removed file is deleted from the local mirror and dropped from the
+// path index and SHA cache.
+func TestApplyChangeDownloadsAndRemoves(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "test-changes")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	originalDownloadDir := downloadDir
+	downloadDir = tmpDir
+	defer func() { downloadDir = originalDownloadDir }()
+
+	mockAPI := &mockChangesAPI{
+		filesByID: map[string]*drive.File{},
+		fileContent: map[string]string{
+			"file1_id": "updated content",
+		},
+		folders: map[string]string{"test-folder": "root_folder_id"},
+	}
+
+	state := &syncState{
+		PathIndex: map[string]string{
+			"root_folder_id": tmpDir,
+			"file2_id":       filepath.Join(tmpDir, "old.txt"),
+		},
+		ShaCache: map[string]string{
+			filepath.Join(tmpDir, "old.txt"): "sha_old",
+		},
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "old.txt"), []byte("stale"), 0644); err != nil {
+		t.Fatalf("Failed to seed existing file: %v", err)
+	}
+
+	exportExts := parseExportExtensions(defaultExportExtensions)
+
+	// A new/changed file under the root should be downloaded.
+	applyChange(context.Background(), mockAPI, "root_folder_id", &drive.Change{
+		FileId: "file1_id",
+		File: &drive.File{
+			Id:             "file1_id",
+			Name:           "file1.txt",
+			MimeType:       "text/plain",
+			Parents:        []string{"root_folder_id"},
+			Sha256Checksum: "sha_file1",
+		},
+	}, state, exportExts, pruneOptions{})
+
+	assertFileContent(t, filepath.Join(tmpDir, "file1.txt"), "updated content")
+	if state.PathIndex["file1_id"] != filepath.Join(tmpDir, "file1.txt") {
+		t.Errorf("path index not updated for file1_id: got %v", state.PathIndex["file1_id"])
+	}
+
+	// A removed file should be deleted locally and dropped from bookkeeping.
+	applyChange(context.Background(), mockAPI, "root_folder_id", &drive.Change{
+		FileId:  "file2_id",
+		Removed: true,
+	}, state, exportExts, pruneOptions{})
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "old.txt")); !os.IsNotExist(err) {
+		t.Errorf("old.txt was not removed")
+	}
+	if _, ok := state.PathIndex["file2_id"]; ok {
+		t.Errorf("file2_id was not dropped from the path index")
+	}
+	if _, ok := state.ShaCache[filepath.Join(tmpDir, "old.txt")]; ok {
+		t.Errorf("old.txt was not dropped from the SHA cache")
+	}
+}
+
+// TestApplyChangeReexportsWithoutSpuriousMove verifies that re-applying an unchanged Workspace
+// doc's Changes feed entry doesn't misdetect a move from the exported path ("doc.docx") the path
+// index is seeded with to the bare Drive name ("doc").
+func TestApplyChangeReexportsWithoutSpuriousMove(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "test-changes-reexport")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	originalDownloadDir := downloadDir
+	downloadDir = tmpDir
+	defer func() { downloadDir = originalDownloadDir }()
+
+	mockAPI := &mockChangesAPI{
+		filesByID:   map[string]*drive.File{},
+		fileContent: map[string]string{"doc_id": "exported content"},
+		folders:     map[string]string{"test-folder": "root_folder_id"},
+	}
+
+	state := &syncState{
+		PathIndex: map[string]string{"root_folder_id": tmpDir},
+		ShaCache:  map[string]string{},
+	}
+	exportExts := parseExportExtensions(defaultExportExtensions)
+
+	docChange := &drive.Change{
+		FileId: "doc_id",
+		File: &drive.File{
+			Id:           "doc_id",
+			Name:         "doc",
+			MimeType:     "application/vnd.google-apps.document",
+			Parents:      []string{"root_folder_id"},
+			ModifiedTime: "2026-01-01T00:00:00Z",
+		},
+	}
+
+	// First application: downloads and seeds the path index with the exported path.
+	applyChange(context.Background(), mockAPI, "root_folder_id", docChange, state, exportExts, pruneOptions{})
+	wantPath := filepath.Join(tmpDir, "doc.docx")
+	assertFileContent(t, wantPath, "exported content")
+	if state.PathIndex["doc_id"] != wantPath {
+		t.Fatalf("path index not seeded with exported path: got %v", state.PathIndex["doc_id"])
+	}
+
+	// Second application of the same unchanged change must not rename doc.docx away.
+	applyChange(context.Background(), mockAPI, "root_folder_id", docChange, state, exportExts, pruneOptions{})
+	assertFileContent(t, wantPath, "exported content")
+	if state.PathIndex["doc_id"] != wantPath {
+		t.Errorf("path index corrupted by a spurious move: got %v", state.PathIndex["doc_id"])
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "doc")); !os.IsNotExist(err) {
+		t.Errorf("an orphaned 'doc' file was created by a spurious move")
+	}
+}
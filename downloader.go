@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// defaultParallelDownloads is the --parallel-downloads value used when the flag is left at its
+// default.
+const defaultParallelDownloads = 4
+
+// downloadJob is one file queued for the "fetch + write" step; the checksum comparison against
+// shaCache ("decide + enqueue") has already happened by the time a job reaches the channel.
+type downloadJob struct {
+	file   *drive.File
+	dir    string
+	export *exportFormat
+}
+
+// downloadPool runs downloads on a fixed number of worker goroutines so a folder with many small
+// files isn't limited to one round-trip at a time. shaCache and pathIndex are shared with the
+// (single-threaded) recursive walk, so every access to them is guarded by mu.
+type downloadPool struct {
+	driveAPI  DriveAPI
+	jobs      chan downloadJob
+	wg        sync.WaitGroup
+	mu        sync.Mutex
+	shaCache  map[string]string
+	pathIndex map[string]string
+}
+
+// newDownloadPool starts parallel worker goroutines waiting for jobs on an internal channel.
+func newDownloadPool(driveAPI DriveAPI, shaCache, pathIndex map[string]string, parallel int) *downloadPool {
+	if parallel < 1 {
+		parallel = 1
+	}
+	p := &downloadPool{
+		driveAPI:  driveAPI,
+		jobs:      make(chan downloadJob, parallel),
+		shaCache:  shaCache,
+		pathIndex: pathIndex,
+	}
+	for i := 0; i < parallel; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *downloadPool) work() {
+	for job := range p.jobs {
+		p.process(job)
+		p.wg.Done()
+	}
+}
+
+// enqueue is the "decide" half of the old downloadFile: it skips the transfer entirely if the
+// local copy's cached staleness value (staleCheckValue) already matches, moves a matching trashed
+// copy back into place instead if restoreFromTrash finds one, and otherwise hands the job to a
+// worker.
+func (p *downloadPool) enqueue(file *drive.File, dir string, export *exportFormat) {
+	localPath := exportedPath(file, dir, export)
+	staleValue := staleCheckValue(file, export)
+
+	p.mu.Lock()
+	cached, found := p.shaCache[localPath]
+	p.mu.Unlock()
+
+	if found && cached == staleValue {
+		if _, err := os.Stat(localPath); err == nil {
+			p.setPathIndex(file.Id, localPath)
+			return
+		}
+	}
+
+	if restored, err := restoreFromTrash(localPath, staleValue); err != nil {
+		log.Printf("Failed to restore %s from trash: %v", localPath, err)
+	} else if restored {
+		fmt.Printf("File '%s' restored from trash.\n", filepath.Base(localPath))
+		p.mu.Lock()
+		p.shaCache[localPath] = staleValue
+		if p.pathIndex != nil {
+			p.pathIndex[file.Id] = localPath
+		}
+		p.mu.Unlock()
+		return
+	}
+
+	p.wg.Add(1)
+	p.jobs <- downloadJob{file: file, dir: dir, export: export}
+}
+
+// process is the "fetch + write" half, run on a worker goroutine.
+func (p *downloadPool) process(job downloadJob) {
+	name := job.file.Name
+	if job.export != nil {
+		name += "." + job.export.ext
+	}
+	fmt.Printf("Downloading '%s'...\n", name)
+
+	localPath, err := fetchAndWrite(p.driveAPI, job.file, job.dir, job.export)
+	if err != nil {
+		log.Printf("%v", err)
+		return
+	}
+
+	p.mu.Lock()
+	p.shaCache[localPath] = staleCheckValue(job.file, job.export)
+	if p.pathIndex != nil {
+		p.pathIndex[job.file.Id] = localPath
+	}
+	p.mu.Unlock()
+}
+
+// setPathIndex records fileID -> localPath in pathIndex, guarded by the same mutex that protects
+// it from the worker goroutines in process/enqueue. The single-threaded recursive walk must route
+// its own folder-path writes through this instead of writing pathIndex directly, since workers can
+// be updating it concurrently.
+func (p *downloadPool) setPathIndex(fileID, localPath string) {
+	if p.pathIndex == nil {
+		return
+	}
+	p.mu.Lock()
+	p.pathIndex[fileID] = localPath
+	p.mu.Unlock()
+}
+
+// wait closes the job queue and blocks until every enqueued download has finished. Callers must
+// call this before relying on shaCache/pathIndex being complete, and in particular before
+// pruning so pruning never races with an in-flight write.
+func (p *downloadPool) wait() {
+	close(p.jobs)
+	p.wg.Wait()
+}
+
+// exportedPath returns the local path a file (or, if export is non-nil, its exported copy) will
+// be written to.
+func exportedPath(file *drive.File, dir string, export *exportFormat) string {
+	name := file.Name
+	if export != nil {
+		name += "." + export.ext
+	}
+	return filepath.Join(dir, name)
+}
+
+// fetchAndWrite downloads file (or exports it, if export is non-nil) into dir. It streams the
+// response into a "dir/.name.partial" temp file and renames it into place only once the copy
+// succeeds, so a crash or error mid-transfer never leaves a truncated file on disk cached under
+// the remote checksum.
+func fetchAndWrite(driveAPI DriveAPI, file *drive.File, dir string, export *exportFormat) (string, error) {
+	name := file.Name
+	if export != nil {
+		name += "." + export.ext
+	}
+	localPath := filepath.Join(dir, name)
+	tmpPath := filepath.Join(dir, "."+name+".partial")
+
+	var body io.ReadCloser
+	var err error
+	if export != nil {
+		body, err = driveAPI.ExportFile(file.Id, export.mimeType)
+	} else {
+		body, err = driveAPI.DownloadFile(file.Id)
+	}
+	if err != nil {
+		return localPath, fmt.Errorf("error downloading %s: %w", name, err)
+	}
+	defer body.Close()
+
+	outFile, err := os.Create(tmpPath)
+	if err != nil {
+		return localPath, fmt.Errorf("error creating temp file %s: %w", tmpPath, err)
+	}
+
+	if _, err := io.Copy(outFile, body); err != nil {
+		outFile.Close()
+		os.Remove(tmpPath)
+		return localPath, fmt.Errorf("error writing to file %s: %w", tmpPath, err)
+	}
+	outFile.Close()
+
+	if err := os.Rename(tmpPath, localPath); err != nil {
+		os.Remove(tmpPath)
+		return localPath, fmt.Errorf("error finalizing file %s: %w", localPath, err)
+	}
+	return localPath, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// concurrencyTrackingAPI wraps mockDriveAPI's data but records how many DownloadFile calls are
+// in flight at once, so tests can assert the worker pool respects its concurrency limit.
+type concurrencyTrackingAPI struct {
+	mockDriveAPI
+	inFlight    int32
+	maxInFlight int32
+}
+
+func (m *concurrencyTrackingAPI) DownloadFile(fileID string) (io.ReadCloser, error) {
+	current := atomic.AddInt32(&m.inFlight, 1)
+	for {
+		max := atomic.LoadInt32(&m.maxInFlight)
+		if current <= max || atomic.CompareAndSwapInt32(&m.maxInFlight, max, current) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	defer atomic.AddInt32(&m.inFlight, -1)
+
+	content, ok := m.fileContent[fileID]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+// TestPerformSyncConcurrentDownloads downloads a folder with many files and verifies every
+// file's content round-trips correctly and that no more than --parallel-downloads transfers run
+// at the same time.
+func TestPerformSyncConcurrentDownloads(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "test-sync-concurrent")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	originalDownloadDir := downloadDir
+	downloadDir = tmpDir
+	defer func() { downloadDir = originalDownloadDir }()
+
+	const numFiles = 60
+	const parallel = 4
+
+	var files []*drive.File
+	fileContent := make(map[string]string)
+	for i := 0; i < numFiles; i++ {
+		id := fmt.Sprintf("file%d_id", i)
+		name := fmt.Sprintf("file%d.txt", i)
+		content := fmt.Sprintf("content-%d", i)
+		files = append(files, &drive.File{Id: id, Name: name, MimeType: "text/plain", Sha256Checksum: "sha_" + id})
+		fileContent[id] = content
+	}
+
+	mockAPI := &concurrencyTrackingAPI{
+		mockDriveAPI: mockDriveAPI{
+			files: map[string][]*drive.File{
+				"root_folder_id": files,
+			},
+			folders:     map[string]string{"test-folder": "root_folder_id"},
+			fileContent: fileContent,
+		},
+	}
+
+	shaCache := make(map[string]string)
+	exportExts := parseExportExtensions(defaultExportExtensions)
+	_, err = performSync(context.Background(), mockAPI, "test-folder", time.Time{}, shaCache, exportExts, nil, parallel, pruneOptions{safetyRatio: defaultPruneSafetyRatio})
+	if err != nil {
+		t.Fatalf("performSync failed: %v", err)
+	}
+
+	for i := 0; i < numFiles; i++ {
+		name := fmt.Sprintf("file%d.txt", i)
+		assertFileContent(t, filepath.Join(tmpDir, name), fmt.Sprintf("content-%d", i))
+	}
+
+	if max := atomic.LoadInt32(&mockAPI.maxInFlight); max > parallel {
+		t.Errorf("expected at most %d concurrent downloads, saw %d", parallel, max)
+	}
+
+	// No leftover .partial temp files should remain.
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read tmpDir: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".partial") {
+			t.Errorf("leftover partial file: %s", entry.Name())
+		}
+	}
+}
+
+// TestPerformSyncConcurrentDownloadsWithPathIndex exercises a non-nil pathIndex (as used by the
+// changes-mode initial walk) alongside a bounded worker pool and a mix of files and sub-folders,
+// so the recursive walk's folder-path bookkeeping and the pool's concurrent file-path bookkeeping
+// are both writing at once. Run with `go test -race` to catch unsynchronized map writes between
+// them.
+func TestPerformSyncConcurrentDownloadsWithPathIndex(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "test-sync-concurrent-pathindex")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	originalDownloadDir := downloadDir
+	downloadDir = tmpDir
+	defer func() { downloadDir = originalDownloadDir }()
+
+	const numSubfolders = 20
+	const filesPerFolder = 3
+	const parallel = 4
+
+	rootFiles := []*drive.File{}
+	filesByParent := map[string][]*drive.File{}
+	fileContent := make(map[string]string)
+	for i := 0; i < numSubfolders; i++ {
+		folderID := fmt.Sprintf("folder%d_id", i)
+		rootFiles = append(rootFiles, &drive.File{Id: folderID, Name: fmt.Sprintf("folder%d", i), MimeType: "application/vnd.google-apps.folder"})
+
+		var children []*drive.File
+		for j := 0; j < filesPerFolder; j++ {
+			id := fmt.Sprintf("folder%d_file%d_id", i, j)
+			name := fmt.Sprintf("file%d.txt", j)
+			children = append(children, &drive.File{Id: id, Name: name, MimeType: "text/plain", Sha256Checksum: "sha_" + id})
+			fileContent[id] = fmt.Sprintf("content-%d-%d", i, j)
+		}
+		filesByParent[folderID] = children
+	}
+	filesByParent["root_folder_id"] = rootFiles
+
+	mockAPI := &concurrencyTrackingAPI{
+		mockDriveAPI: mockDriveAPI{
+			files:       filesByParent,
+			folders:     map[string]string{"test-folder": "root_folder_id"},
+			fileContent: fileContent,
+		},
+	}
+
+	shaCache := make(map[string]string)
+	pathIndex := make(map[string]string)
+	exportExts := parseExportExtensions(defaultExportExtensions)
+	_, err = performSync(context.Background(), mockAPI, "test-folder", time.Time{}, shaCache, exportExts, pathIndex, parallel, pruneOptions{safetyRatio: defaultPruneSafetyRatio})
+	if err != nil {
+		t.Fatalf("performSync failed: %v", err)
+	}
+
+	if len(pathIndex) != 1+numSubfolders+numSubfolders*filesPerFolder {
+		t.Errorf("expected %d path index entries, got %d", 1+numSubfolders+numSubfolders*filesPerFolder, len(pathIndex))
+	}
+	for i := 0; i < numSubfolders; i++ {
+		for j := 0; j < filesPerFolder; j++ {
+			assertFileContent(t, filepath.Join(tmpDir, fmt.Sprintf("folder%d", i), fmt.Sprintf("file%d.txt", j)), fmt.Sprintf("content-%d-%d", i, j))
+		}
+	}
+}
@@ -13,29 +13,40 @@ import (
 type DriveAPI interface {
 	ListFiles(ctx context.Context, query string) ([]*drive.File, error)
 	DownloadFile(fileID string) (io.ReadCloser, error)
+	ExportFile(fileID, mimeType string) (io.ReadCloser, error)
 	GetFolderID(ctx context.Context, name string) (string, error)
+	GetFile(ctx context.Context, fileID string) (*drive.File, error)
+	GetStartPageToken(ctx context.Context) (string, error)
+	ListChanges(ctx context.Context, pageToken string) (changes []*drive.Change, nextPageToken, newStartPageToken string, err error)
 }
 
-// driveService implements the DriveAPI interface using the real Google Drive service.
+// driveService implements the DriveAPI interface using the real Google Drive service. Every
+// method paces itself through pacer, retrying rate-limit and server errors with backoff instead
+// of bubbling them straight up to the sync loop.
 type driveService struct {
-	srv *drive.Service
+	srv   *drive.Service
+	pacer *pacer
 }
 
 // NewDriveService creates a new wrapper for the real drive service.
 func NewDriveService(srv *drive.Service) DriveAPI {
-	return &driveService{srv: srv}
+	return &driveService{srv: srv, pacer: newPacer()}
 }
 
 func (ds *driveService) ListFiles(ctx context.Context, query string) ([]*drive.File, error) {
 	var files []*drive.File
-	err := ds.srv.Files.List().
-		Context(ctx).
-		Q(query).
-		Fields("files(id, name, mimeType, modifiedTime, sha256Checksum)").
-		Pages(ctx, func(page *drive.FileList) error {
-			files = append(files, page.Files...)
-			return nil
-		})
+	err := ds.pacer.Call(func() (bool, error) {
+		files = nil
+		callErr := ds.srv.Files.List().
+			Context(ctx).
+			Q(query).
+			Fields("files(id, name, mimeType, modifiedTime, sha256Checksum)").
+			Pages(ctx, func(page *drive.FileList) error {
+				files = append(files, page.Files...)
+				return nil
+			})
+		return shouldRetry(callErr), callErr
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -43,11 +54,38 @@ func (ds *driveService) ListFiles(ctx context.Context, query string) ([]*drive.F
 }
 
 func (ds *driveService) DownloadFile(fileID string) (io.ReadCloser, error) {
-	resp, err := ds.srv.Files.Get(fileID).Download()
+	var body io.ReadCloser
+	err := ds.pacer.Call(func() (bool, error) {
+		resp, callErr := ds.srv.Files.Get(fileID).Download()
+		if callErr != nil {
+			return shouldRetry(callErr), callErr
+		}
+		body = resp.Body
+		return false, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	return resp.Body, nil
+	return body, nil
+}
+
+// ExportFile downloads a Google Workspace document (Docs, Sheets, Slides, Drawings, ...)
+// converted to the given export MIME type, since Workspace files have no binary content of
+// their own to download directly.
+func (ds *driveService) ExportFile(fileID, mimeType string) (io.ReadCloser, error) {
+	var body io.ReadCloser
+	err := ds.pacer.Call(func() (bool, error) {
+		resp, callErr := ds.srv.Files.Export(fileID, mimeType).Download()
+		if callErr != nil {
+			return shouldRetry(callErr), callErr
+		}
+		body = resp.Body
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return body, nil
 }
 
 func (ds *driveService) GetFolderID(ctx context.Context, name string) (string, error) {
@@ -61,3 +99,60 @@ func (ds *driveService) GetFolderID(ctx context.Context, name string) (string, e
 	}
 	return files[0].Id, nil
 }
+
+// GetFile fetches a single file's metadata, used by the changes syncer to walk a changed file's
+// ancestors back up to the watched root folder.
+func (ds *driveService) GetFile(ctx context.Context, fileID string) (*drive.File, error) {
+	var file *drive.File
+	err := ds.pacer.Call(func() (bool, error) {
+		var callErr error
+		file, callErr = ds.srv.Files.Get(fileID).
+			Context(ctx).
+			Fields("id, name, mimeType, parents, trashed, sha256Checksum").
+			Do()
+		return shouldRetry(callErr), callErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// GetStartPageToken returns the page token marking "now" in the Changes feed, used to seed
+// incremental sync after the initial full walk.
+func (ds *driveService) GetStartPageToken(ctx context.Context) (string, error) {
+	var startToken string
+	err := ds.pacer.Call(func() (bool, error) {
+		token, callErr := ds.srv.Changes.GetStartPageToken().Context(ctx).Do()
+		if callErr != nil {
+			return shouldRetry(callErr), callErr
+		}
+		startToken = token.StartPageToken
+		return false, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return startToken, nil
+}
+
+// ListChanges returns one page of the Changes feed starting at pageToken. Callers should keep
+// calling with nextPageToken until it comes back empty, at which point newStartPageToken is the
+// token to persist for the next poll.
+func (ds *driveService) ListChanges(ctx context.Context, pageToken string) (changes []*drive.Change, nextPageToken, newStartPageToken string, err error) {
+	callErr := ds.pacer.Call(func() (bool, error) {
+		page, listErr := ds.srv.Changes.List(pageToken).
+			Context(ctx).
+			Fields("nextPageToken, newStartPageToken, changes(fileId, removed, file(id, name, mimeType, parents, modifiedTime, sha256Checksum, trashed))").
+			Do()
+		if listErr != nil {
+			return shouldRetry(listErr), listErr
+		}
+		changes, nextPageToken, newStartPageToken = page.Changes, page.NextPageToken, page.NewStartPageToken
+		return false, nil
+	})
+	if callErr != nil {
+		return nil, "", "", callErr
+	}
+	return changes, nextPageToken, newStartPageToken, nil
+}
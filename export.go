@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// defaultExportExtensions is the --export-extensions value used when the flag is not given,
+// mirroring rclone's defaultExtensions for the Google Drive backend.
+const defaultExportExtensions = "docx,xlsx,pptx,svg"
+
+// exportFormat pairs a file extension with the Drive export MIME type that produces it.
+type exportFormat struct {
+	ext      string
+	mimeType string
+}
+
+// workspaceExportFormats lists, per Google Workspace MIME type, the export formats Drive can
+// produce for it, most-preferred first. resolveExportFormat picks the first entry whose
+// extension also appears in the user's --export-extensions list.
+var workspaceExportFormats = map[string][]exportFormat{
+	"application/vnd.google-apps.document": {
+		{ext: "docx", mimeType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{ext: "pdf", mimeType: "application/pdf"},
+	},
+	"application/vnd.google-apps.spreadsheet": {
+		{ext: "xlsx", mimeType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+		{ext: "csv", mimeType: "text/csv"},
+		{ext: "pdf", mimeType: "application/pdf"},
+	},
+	"application/vnd.google-apps.presentation": {
+		{ext: "pptx", mimeType: "application/vnd.openxmlformats-officedocument.presentationml.presentation"},
+		{ext: "pdf", mimeType: "application/pdf"},
+	},
+	"application/vnd.google-apps.drawing": {
+		{ext: "svg", mimeType: "image/svg+xml"},
+		{ext: "pdf", mimeType: "application/pdf"},
+	},
+}
+
+// parseExportExtensions turns a comma-separated --export-extensions flag value into a lookup
+// set, trimming whitespace and ignoring empty entries.
+func parseExportExtensions(flagValue string) map[string]bool {
+	allowed := make(map[string]bool)
+	for _, ext := range strings.Split(flagValue, ",") {
+		ext = strings.TrimSpace(ext)
+		if ext != "" {
+			allowed[ext] = true
+		}
+	}
+	return allowed
+}
+
+// resolveExportFormat returns the export format to use for a Google Workspace file of the given
+// MIME type, or false if the type has no known export mapping or none of its formats are in the
+// allowed extension set.
+func resolveExportFormat(mimeType string, allowedExts map[string]bool) (exportFormat, bool) {
+	for _, format := range workspaceExportFormats[mimeType] {
+		if allowedExts[format.ext] {
+			return format, true
+		}
+	}
+	return exportFormat{}, false
+}
+
+// staleCheckValue returns the value used to detect whether file's local copy is out of date.
+// Drive never populates sha256Checksum on Google Workspace files (application/vnd.google-apps.*)
+// since they have no binary content of their own, so an exported file is staled against its
+// modifiedTime instead.
+func staleCheckValue(file *drive.File, export *exportFormat) string {
+	if export != nil {
+		return file.ModifiedTime
+	}
+	return file.Sha256Checksum
+}
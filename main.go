@@ -18,11 +18,25 @@ func main() {
 	// Define command-line flags
 	folderName := flag.String("folder-name", "agents", "Name of the folder to search within (required)")
 	secondsAgo := flag.Int("seconds-ago", 0, "On the first run, list files modified in the last N seconds. If 0, all files are synced.")
+	exportExtensions := flag.String("export-extensions", defaultExportExtensions, "Comma-separated list of file extensions to export Google Workspace documents (Docs, Sheets, Slides, Drawings) as.")
+	mode := flag.String("mode", syncModeWalk, "Sync mode: 'walk' re-lists the whole folder tree every cycle, 'changes' does one initial walk and then applies the Drive Changes feed incrementally.")
+	parallelDownloads := flag.Int("parallel-downloads", defaultParallelDownloads, "Number of files to download concurrently.")
+	useTrash := flag.Bool("use-trash", false, "Move pruned local files into downloadDir/.trash instead of deleting them outright.")
+	trashRetention := flag.Duration("trash-retention", defaultTrashRetention, "How long trashed files are kept (with --use-trash) before being permanently removed.")
+	pruneSafetyRatio := flag.Float64("prune-safety-ratio", defaultPruneSafetyRatio, "Abort pruning a sync cycle if it would remove more than this fraction of the existing local mirror.")
 	flag.Parse()
 
 	if *folderName == "" {
 		log.Fatalf("The --folder-name flag is required.")
 	}
+	if *mode != syncModeWalk && *mode != syncModeChanges {
+		log.Fatalf("Invalid --mode %q: must be %q or %q.", *mode, syncModeWalk, syncModeChanges)
+	}
+	pruneOpts := pruneOptions{
+		useTrash:       *useTrash,
+		trashRetention: *trashRetention,
+		safetyRatio:    *pruneSafetyRatio,
+	}
 
 	// Set up a context that can be cancelled.
 	ctx, cancel := context.WithCancel(context.Background())
@@ -45,7 +59,7 @@ func main() {
 	}
 
 	// Start the background file syncing process, passing the new key path argument.
-	go startSyncLoop(ctx, *folderName, *secondsAgo)
+	go startSyncLoop(ctx, *folderName, *secondsAgo, *exportExtensions, *mode, *parallelDownloads, pruneOpts)
 
 	// Set up and start the HTTP server
 	http.HandleFunc("/", fileHandler)
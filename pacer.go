@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// pacer throttles and retries Drive API calls with exponential backoff, in the spirit of
+// rclone's Drive backend pacer: sleep decays on success and grows on a retryable error, so a
+// quiet API settles back down to minSleep while a rate-limited one backs off fast.
+type pacer struct {
+	mu            sync.Mutex
+	sleep         time.Duration
+	minSleep      time.Duration
+	maxSleep      time.Duration
+	decayConstant time.Duration
+	maxRetries    int
+}
+
+// newPacer returns a pacer configured with the same defaults rclone uses for Drive.
+func newPacer() *pacer {
+	return newPacerWithConfig(10*time.Millisecond, 2*time.Second, 2, 10)
+}
+
+func newPacerWithConfig(minSleep, maxSleep time.Duration, decayConstant time.Duration, maxRetries int) *pacer {
+	return &pacer{
+		sleep:         minSleep,
+		minSleep:      minSleep,
+		maxSleep:      maxSleep,
+		decayConstant: decayConstant,
+		maxRetries:    maxRetries,
+	}
+}
+
+// currentSleep returns the sleep duration the pacer would currently use, exposed for tests.
+func (p *pacer) currentSleep() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.sleep
+}
+
+// grow doubles the current sleep (capped at maxSleep), then raises it further to honor minWait
+// if the server told us explicitly how long to wait via Retry-After.
+func (p *pacer) grow(minWait time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sleep *= 2
+	if p.sleep > p.maxSleep {
+		p.sleep = p.maxSleep
+	}
+	if minWait > p.sleep {
+		p.sleep = minWait
+	}
+}
+
+// decay shrinks the current sleep back toward minSleep after a successful call.
+func (p *pacer) decay() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sleep /= p.decayConstant
+	if p.sleep < p.minSleep {
+		p.sleep = p.minSleep
+	}
+}
+
+// Call invokes fn, retrying with exponential backoff while fn reports the error as retryable. fn
+// returns (retry, err): retry tells the pacer whether err is worth retrying. Call gives up after
+// maxRetries retries (maxRetries+1 attempts in all) and returns the last error, wrapped.
+func (p *pacer) Call(fn func() (bool, error)) error {
+	var err error
+	for attempt := 0; attempt <= p.maxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(p.currentSleep())
+		}
+
+		var retry bool
+		retry, err = fn()
+		if !retry {
+			p.decay()
+			return err
+		}
+		p.grow(retryAfter(err))
+	}
+	return fmt.Errorf("giving up after %d retries: %w", p.maxRetries, err)
+}
+
+// shouldRetry classifies a Drive API error as retryable: rate limiting (403
+// userRateLimitExceeded/rateLimitExceeded, or 429) and 5xx server errors are all transient.
+func shouldRetry(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch {
+	case apiErr.Code == 429, apiErr.Code >= 500:
+		return true
+	case apiErr.Code == 403:
+		for _, e := range apiErr.Errors {
+			if e.Reason == "userRateLimitExceeded" || e.Reason == "rateLimitExceeded" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// retryAfter reads the Retry-After response header off a googleapi.Error, if present, so the
+// pacer can honor a server-specified wait instead of guessing.
+func retryAfter(err error) time.Duration {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) || apiErr.Header == nil {
+		return 0
+	}
+	value := apiErr.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, convErr := strconv.Atoi(value); convErr == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// TestPacerCallSucceedsAfterRetries verifies that Call keeps retrying a retryable error and
+// eventually returns the result of a later successful attempt.
+func TestPacerCallSucceedsAfterRetries(t *testing.T) {
+	p := newPacerWithConfig(time.Millisecond, 10*time.Millisecond, 2, 5)
+
+	attempts := 0
+	err := p.Call(func() (bool, error) {
+		attempts++
+		if attempts < 3 {
+			rateLimitErr := &googleapi.Error{Code: 429, Message: "rate limited"}
+			return shouldRetry(rateLimitErr), rateLimitErr
+		}
+		return false, nil
+	})
+	if err != nil {
+		t.Fatalf("Call returned error after eventual success: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+// TestPacerCallGivesUpAfterMaxRetries verifies that Call stops retrying and surfaces the last
+// error once maxRetries is exceeded.
+func TestPacerCallGivesUpAfterMaxRetries(t *testing.T) {
+	p := newPacerWithConfig(time.Millisecond, 5*time.Millisecond, 2, 3)
+
+	attempts := 0
+	err := p.Call(func() (bool, error) {
+		attempts++
+		rateLimitErr := &googleapi.Error{Code: 500, Message: "server error"}
+		return shouldRetry(rateLimitErr), rateLimitErr
+	})
+	if err == nil {
+		t.Fatal("expected Call to return an error after exhausting retries")
+	}
+	if attempts != 4 {
+		t.Errorf("expected 4 attempts (1 + 3 retries), got %d", attempts)
+	}
+}
+
+// TestPacerSleepGrowsAndDecays verifies the sleep duration doubles on a retryable error (capped
+// at maxSleep) and shrinks back toward minSleep on success.
+func TestPacerSleepGrowsAndDecays(t *testing.T) {
+	p := newPacerWithConfig(10*time.Millisecond, 100*time.Millisecond, 2, 10)
+
+	p.grow(0)
+	if got := p.currentSleep(); got != 20*time.Millisecond {
+		t.Errorf("expected sleep to double to 20ms, got %v", got)
+	}
+
+	p.grow(0)
+	p.grow(0)
+	p.grow(0)
+	if got := p.currentSleep(); got != 100*time.Millisecond {
+		t.Errorf("expected sleep to cap at maxSleep (100ms), got %v", got)
+	}
+
+	p.decay()
+	if got := p.currentSleep(); got != 50*time.Millisecond {
+		t.Errorf("expected sleep to halve to 50ms, got %v", got)
+	}
+}
+
+// TestShouldRetryClassification checks which Drive API errors are treated as retryable.
+func TestShouldRetryClassification(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rate limit exceeded 403", &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}, true},
+		{"user rate limit exceeded 403", &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "userRateLimitExceeded"}}}, true},
+		{"permission denied 403", &googleapi.Error{Code: 403, Errors: []googleapi.ErrorItem{{Reason: "insufficientPermissions"}}}, false},
+		{"too many requests 429", &googleapi.Error{Code: 429}, true},
+		{"server error 503", &googleapi.Error{Code: 503}, true},
+		{"not found 404", &googleapi.Error{Code: 404}, false},
+		{"nil error", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldRetry(tt.err); got != tt.want {
+				t.Errorf("shouldRetry(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRetryAfterHeader verifies that retryAfter reads a Retry-After response header (in seconds)
+// off a googleapi.Error as the wait the pacer's grow will honor.
+func TestRetryAfterHeader(t *testing.T) {
+	header := http.Header{}
+	header.Set("Retry-After", "3")
+	err := &googleapi.Error{Code: 429, Header: header}
+
+	if got := retryAfter(err); got != 3*time.Second {
+		t.Errorf("retryAfter() = %v, want 3s", got)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// stateFileName is the name of the JSON file persisted inside downloadDir so that changes-mode
+// sync can resume from where it left off after a restart instead of forcing a full re-walk.
+const stateFileName = ".run-drive-state.json"
+
+// syncState is the subset of in-memory sync bookkeeping that changes-mode sync persists to disk:
+// the Changes feed page token, the fileID -> local path index used to resolve changed files'
+// paths, and the checksum cache used to skip unchanged downloads.
+type syncState struct {
+	PageToken string            `json:"pageToken"`
+	PathIndex map[string]string `json:"pathIndex"`
+	ShaCache  map[string]string `json:"shaCache"`
+}
+
+func stateFilePath() string {
+	return filepath.Join(downloadDir, stateFileName)
+}
+
+// loadState reads the persisted sync state, returning a freshly initialized state if none
+// exists yet (e.g. the first run).
+func loadState() (*syncState, error) {
+	data, err := os.ReadFile(stateFilePath())
+	if os.IsNotExist(err) {
+		return &syncState{PathIndex: make(map[string]string), ShaCache: make(map[string]string)}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state syncState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	if state.PathIndex == nil {
+		state.PathIndex = make(map[string]string)
+	}
+	if state.ShaCache == nil {
+		state.ShaCache = make(map[string]string)
+	}
+	return &state, nil
+}
+
+// save persists the sync state to downloadDir so the next startSyncLoop invocation can resume
+// incremental sync without a full re-walk.
+func (s *syncState) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(stateFilePath(), data, 0644)
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"context"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -20,8 +19,16 @@ var downloadDir = "/tmp/agents-state"
 
 const syncInterval = 30 * time.Second
 
-// startSyncLoop runs the file synchronization process in a continuous loop.
-func startSyncLoop(ctx context.Context, folderName string, secondsAgo int) {
+// Sync modes selectable via --mode.
+const (
+	syncModeWalk    = "walk"
+	syncModeChanges = "changes"
+)
+
+// startSyncLoop runs the file synchronization process in a continuous loop. In "walk" mode
+// (the default) every cycle re-lists the whole folder tree; in "changes" mode only the first
+// cycle walks the tree, and later cycles apply the Drive Changes feed incrementally.
+func startSyncLoop(ctx context.Context, folderName string, secondsAgo int, exportExtensions, mode string, parallelDownloads int, pruneOpts pruneOptions) {
 	var driveAPI DriveAPI
 
 	log.Println("Authenticating using Application Default Credentials.")
@@ -35,6 +42,13 @@ func startSyncLoop(ctx context.Context, folderName string, secondsAgo int) {
 	}
 	driveAPI = NewDriveService(driveService)
 
+	exportExts := parseExportExtensions(exportExtensions)
+
+	if mode == syncModeChanges {
+		startChangesSyncLoop(ctx, driveAPI, folderName, exportExts, parallelDownloads, pruneOpts)
+		return
+	}
+
 	var lastSyncTime time.Time
 	if secondsAgo > 0 {
 		lastSyncTime = time.Now().Add(-time.Duration(secondsAgo) * time.Second)
@@ -49,7 +63,7 @@ func startSyncLoop(ctx context.Context, folderName string, secondsAgo int) {
 			return
 		default:
 			fmt.Printf("\nStarting sync cycle...\n")
-			newSyncTime, err := performSync(ctx, driveAPI, folderName, lastSyncTime, shaCache)
+			newSyncTime, err := performSync(ctx, driveAPI, folderName, lastSyncTime, shaCache, exportExts, nil, parallelDownloads, pruneOpts)
 			if err != nil {
 				log.Printf("Sync cycle failed: %v. Retrying in %v.", err, syncInterval)
 			} else {
@@ -61,8 +75,10 @@ func startSyncLoop(ctx context.Context, folderName string, secondsAgo int) {
 	}
 }
 
-// performSync starts the synchronization process, including pruning of deleted files.
-func performSync(ctx context.Context, driveAPI DriveAPI, folderName string, since time.Time, shaCache map[string]string) (time.Time, error) {
+// performSync starts the synchronization process, including pruning of deleted files. When
+// pathIndex is non-nil, it is populated with fileID -> localPath for every file and folder
+// visited, so a caller can seed incremental (changes-mode) sync from a full walk.
+func performSync(ctx context.Context, driveAPI DriveAPI, folderName string, since time.Time, shaCache map[string]string, exportExts map[string]bool, pathIndex map[string]string, parallelDownloads int, pruneOpts pruneOptions) (time.Time, error) {
 	currentTime := time.Now()
 
 	rootFolderID, err := driveAPI.GetFolderID(ctx, folderName)
@@ -74,13 +90,16 @@ func performSync(ctx context.Context, driveAPI DriveAPI, folderName string, sinc
 	remotePaths[downloadDir] = true
 
 	fmt.Printf("Starting recursive sync for folder '%s'...\n", folderName)
-	err = syncFolderRecursively(ctx, driveAPI, rootFolderID, downloadDir, since, remotePaths, shaCache)
-	if err != nil {
-		return currentTime, fmt.Errorf("recursive sync failed: %w", err)
+	pool := newDownloadPool(driveAPI, shaCache, pathIndex, parallelDownloads)
+	pool.setPathIndex(rootFolderID, downloadDir)
+	walkErr := syncFolderRecursively(ctx, driveAPI, rootFolderID, downloadDir, since, remotePaths, exportExts, pool)
+	pool.wait()
+	if walkErr != nil {
+		return currentTime, fmt.Errorf("recursive sync failed: %w", walkErr)
 	}
 
 	fmt.Println("Sync complete. Pruning local files that were deleted on Drive...")
-	err = pruneLocalFiles(downloadDir, remotePaths, shaCache)
+	err = pruneLocalFiles(downloadDir, remotePaths, shaCache, pruneOpts)
 	if err != nil {
 		return currentTime, fmt.Errorf("failed to prune local files: %w", err)
 	}
@@ -88,7 +107,7 @@ func performSync(ctx context.Context, driveAPI DriveAPI, folderName string, sinc
 	return currentTime, nil
 }
 
-func syncFolderRecursively(ctx context.Context, driveAPI DriveAPI, folderID, localPath string, since time.Time, remotePaths map[string]bool, shaCache map[string]string) error {
+func syncFolderRecursively(ctx context.Context, driveAPI DriveAPI, folderID, localPath string, since time.Time, remotePaths map[string]bool, exportExts map[string]bool, pool *downloadPool) error {
 	query := fmt.Sprintf("'%s' in parents and trashed = false", folderID)
 	files, err := driveAPI.ListFiles(ctx, query)
 	if err != nil {
@@ -100,66 +119,91 @@ func syncFolderRecursively(ctx context.Context, driveAPI DriveAPI, folderID, loc
 
 		if file.MimeType == "application/vnd.google-apps.folder" {
 			remotePaths[newLocalPath] = true
+			pool.setPathIndex(file.Id, newLocalPath)
 			if err := os.MkdirAll(newLocalPath, 0755); err != nil {
 				log.Printf("Failed to create directory %s: %v", newLocalPath, err)
 				continue
 			}
-			if err := syncFolderRecursively(ctx, driveAPI, file.Id, newLocalPath, since, remotePaths, shaCache); err != nil {
+			if err := syncFolderRecursively(ctx, driveAPI, file.Id, newLocalPath, since, remotePaths, exportExts, pool); err != nil {
 				log.Printf("Failed to sync sub-folder %s: %v", file.Name, err)
 			}
 		} else if strings.HasPrefix(file.MimeType, "application/vnd.google-apps.") {
-			log.Printf("Skipping Google Workspace file: %s", file.Name)
-			continue
+			format, ok := resolveExportFormat(file.MimeType, exportExts)
+			if !ok {
+				log.Printf("Skipping Google Workspace file: %s", file.Name)
+				continue
+			}
+			remotePaths[newLocalPath+"."+format.ext] = true
+			pool.enqueue(file, localPath, &format)
 		} else {
 			remotePaths[newLocalPath] = true
-			downloadFile(driveAPI, file, localPath, shaCache)
+			pool.enqueue(file, localPath, nil)
 		}
 	}
 	return nil
 }
 
-func downloadFile(driveAPI DriveAPI, file *drive.File, dir string, shaCache map[string]string) {
-	localPath := filepath.Join(dir, file.Name)
+// downloadFile fetches file from Drive and writes it under dir, skipping the transfer if the
+// local copy's cached staleness value (staleCheckValue) already matches. When export is non-nil,
+// file is a Google Workspace document with no native binary content, so it's converted via the
+// Drive export endpoint and written under a filename suffixed with the chosen extension. Unlike
+// downloadPool, this runs synchronously on the caller's goroutine; it's used by the changes
+// syncer, which applies one change at a time.
+func downloadFile(driveAPI DriveAPI, file *drive.File, dir string, shaCache map[string]string, export *exportFormat) {
+	localPath := exportedPath(file, dir, export)
+	name := filepath.Base(localPath)
+	staleValue := staleCheckValue(file, export)
 
 	if _, err := os.Stat(localPath); err == nil {
-		localSHA256, found := shaCache[localPath]
-		if found && localSHA256 == file.Sha256Checksum {
+		cached, found := shaCache[localPath]
+		if found && cached == staleValue {
 			return
 		}
-		fmt.Printf("File '%s' has changed. Downloading new version.\n", file.Name)
+		fmt.Printf("File '%s' has changed. Downloading new version.\n", name)
 	} else {
-		fmt.Printf("File '%s' not found locally. Downloading.\n", file.Name)
+		if restored, err := restoreFromTrash(localPath, staleValue); err != nil {
+			log.Printf("Failed to restore %s from trash: %v", name, err)
+		} else if restored {
+			fmt.Printf("File '%s' restored from trash.\n", name)
+			shaCache[localPath] = staleValue
+			return
+		}
+		fmt.Printf("File '%s' not found locally. Downloading.\n", name)
 	}
 
-	body, err := driveAPI.DownloadFile(file.Id)
-	if err != nil {
-		log.Printf("Error downloading %s: %v", file.Name, err)
+	if _, err := fetchAndWrite(driveAPI, file, dir, export); err != nil {
+		log.Printf("%v", err)
 		return
 	}
-	defer body.Close()
 
-	outFile, err := os.Create(localPath)
-	if err != nil {
-		log.Printf("Error creating file %s: %v", localPath, err)
-		return
-	}
-	defer outFile.Close()
+	shaCache[localPath] = staleValue
+}
 
-	if _, err := io.Copy(outFile, body); err != nil {
-		log.Printf("Error writing to file %s: %v", localPath, err)
-		delete(shaCache, localPath)
-		return
+// pruneLocalFiles removes (or, with opts.useTrash, soft-deletes) any local path under localRoot
+// that remotePaths no longer claims. As a safety net against a bad or partial Drive listing, it
+// refuses to touch anything if more than opts.safetyRatio of the existing local paths would be
+// pruned, once the mirror is large enough (minPruneSafetyCheckTotal) for that ratio to be
+// meaningful.
+func pruneLocalFiles(localRoot string, remotePaths map[string]bool, shaCache map[string]string, opts pruneOptions) error {
+	if opts.useTrash {
+		if err := sweepTrash(opts.trashRetention); err != nil {
+			log.Printf("Failed to sweep expired trash: %v", err)
+		}
 	}
 
-	shaCache[localPath] = file.Sha256Checksum
-}
-
-func pruneLocalFiles(localRoot string, remotePaths map[string]bool, shaCache map[string]string) error {
 	var pathsToDelete []string
+	total := 0
 	err := filepath.Walk(localRoot, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if path == localRoot {
+			return nil
+		}
+		if info.IsDir() && path == trashDir() {
+			return filepath.SkipDir
+		}
+		total++
 		if _, exists := remotePaths[path]; !exists {
 			pathsToDelete = append(pathsToDelete, path)
 		}
@@ -169,17 +213,30 @@ func pruneLocalFiles(localRoot string, remotePaths map[string]bool, shaCache map
 		return err
 	}
 
+	if total >= minPruneSafetyCheckTotal && float64(len(pathsToDelete))/float64(total) > opts.safetyRatio {
+		return fmt.Errorf("refusing to prune %d of %d local paths (over the %.0f%% safety ratio); skipping this cycle's pruning", len(pathsToDelete), total, opts.safetyRatio*100)
+	}
+
 	sort.Slice(pathsToDelete, func(i, j int) bool {
 		return len(pathsToDelete[i]) > len(pathsToDelete[j])
 	})
 
+	timestamp := time.Now().UTC().Format(trashTimestampFormat)
 	for _, path := range pathsToDelete {
-		fmt.Printf("Pruning deleted item: %s\n", path)
-		if err := os.RemoveAll(path); err != nil {
-			log.Printf("Failed to prune path %s: %v", path, err)
+		if opts.useTrash {
+			fmt.Printf("Trashing deleted item: %s\n", path)
+			if err := moveToTrash(path, timestamp, shaCache[path]); err != nil {
+				log.Printf("Failed to trash path %s: %v", path, err)
+				continue
+			}
 		} else {
-			delete(shaCache, path)
+			fmt.Printf("Pruning deleted item: %s\n", path)
+			if err := os.RemoveAll(path); err != nil {
+				log.Printf("Failed to prune path %s: %v", path, err)
+				continue
+			}
 		}
+		delete(shaCache, path)
 	}
 	return nil
 }
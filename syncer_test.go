@@ -40,6 +40,26 @@ func (m *mockDriveAPI) DownloadFile(fileID string) (io.ReadCloser, error) {
 	return ioutil.NopCloser(strings.NewReader(content)), nil
 }
 
+func (m *mockDriveAPI) ExportFile(fileID, mimeType string) (io.ReadCloser, error) {
+	content, ok := m.fileContent[fileID]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return ioutil.NopCloser(strings.NewReader(content)), nil
+}
+
+func (m *mockDriveAPI) GetFile(ctx context.Context, fileID string) (*drive.File, error) {
+	return nil, os.ErrNotExist
+}
+
+func (m *mockDriveAPI) GetStartPageToken(ctx context.Context) (string, error) {
+	return "", nil
+}
+
+func (m *mockDriveAPI) ListChanges(ctx context.Context, pageToken string) ([]*drive.Change, string, string, error) {
+	return nil, "", "", nil
+}
+
 // TestPerformSync tests the main synchronization logic.
 func TestPerformSync(t *testing.T) {
 	// --- Setup ---
@@ -77,7 +97,8 @@ func TestPerformSync(t *testing.T) {
 
 	// --- Test Execution ---
 	shaCache := make(map[string]string)
-	_, err = performSync(context.Background(), mockAPI, "test-folder", time.Time{}, shaCache)
+	exportExts := parseExportExtensions(defaultExportExtensions)
+	_, err = performSync(context.Background(), mockAPI, "test-folder", time.Time{}, shaCache, exportExts, nil, defaultParallelDownloads, pruneOptions{safetyRatio: defaultPruneSafetyRatio})
 	if err != nil {
 		t.Fatalf("performSync failed: %v", err)
 	}
@@ -97,7 +118,7 @@ func TestPerformSync(t *testing.T) {
 	mockAPI.files["root_folder_id"] = []*drive.File{
 		{Id: "subfolder_id", Name: "subfolder", MimeType: "application/vnd.google-apps.folder"},
 	}
-	_, err = performSync(context.Background(), mockAPI, "test-folder", time.Now(), shaCache)
+	_, err = performSync(context.Background(), mockAPI, "test-folder", time.Now(), shaCache, exportExts, nil, defaultParallelDownloads, pruneOptions{safetyRatio: defaultPruneSafetyRatio})
 	if err != nil {
 		t.Fatalf("second performSync failed: %v", err)
 	}
@@ -108,6 +129,60 @@ func TestPerformSync(t *testing.T) {
 	}
 }
 
+// TestPerformSyncExportsWorkspaceFiles verifies that Google Workspace files (which have no
+// native binary content) are exported to a configured format and stored under a filename
+// suffixed with the chosen extension. It deliberately leaves Sha256Checksum empty on the mock
+// file, since real Drive never populates that field for application/vnd.google-apps.* files, and
+// instead re-checks staleness via modifiedTime.
+func TestPerformSyncExportsWorkspaceFiles(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "test-sync-export")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	originalDownloadDir := downloadDir
+	downloadDir = tmpDir
+	defer func() { downloadDir = originalDownloadDir }()
+
+	mockAPI := &mockDriveAPI{
+		files: map[string][]*drive.File{
+			"root_folder_id": {
+				{Id: "doc_id", Name: "foo", MimeType: "application/vnd.google-apps.document", ModifiedTime: "2026-01-01T00:00:00Z"},
+			},
+		},
+		folders: map[string]string{
+			"test-folder": "root_folder_id",
+		},
+		fileContent: map[string]string{
+			"doc_id": "exported content",
+		},
+	}
+
+	shaCache := make(map[string]string)
+	exportExts := parseExportExtensions(defaultExportExtensions)
+	_, err = performSync(context.Background(), mockAPI, "test-folder", time.Time{}, shaCache, exportExts, nil, defaultParallelDownloads, pruneOptions{safetyRatio: defaultPruneSafetyRatio})
+	if err != nil {
+		t.Fatalf("performSync failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(tmpDir, "foo.docx"), "exported content")
+
+	if shaCache[filepath.Join(tmpDir, "foo.docx")] != "2026-01-01T00:00:00Z" {
+		t.Errorf("staleness cache not populated with modifiedTime for foo.docx")
+	}
+
+	// The doc is edited: its content (and Drive's mock export) changes, but its checksum stays
+	// empty just like the real API. Staleness must be detected via the new modifiedTime.
+	mockAPI.files["root_folder_id"][0].ModifiedTime = "2026-01-02T00:00:00Z"
+	mockAPI.fileContent["doc_id"] = "edited content"
+	if _, err := performSync(context.Background(), mockAPI, "test-folder", time.Now(), shaCache, exportExts, nil, defaultParallelDownloads, pruneOptions{safetyRatio: defaultPruneSafetyRatio}); err != nil {
+		t.Fatalf("second performSync failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(tmpDir, "foo.docx"), "edited content")
+}
+
 // assertFileContent is a helper to check the content of a file.
 func assertFileContent(t *testing.T, path, expectedContent string) {
 	content, err := os.ReadFile(path)
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// trashDirName is the directory under downloadDir that holds soft-deleted files when --use-trash
+// is set, mirroring rclone's driveUseTrash option.
+const trashDirName = ".trash"
+
+// trashTimestampFormat names each trash batch's top-level folder, e.g. downloadDir/.trash/20260730T120000Z/...
+const trashTimestampFormat = "20060102T150405Z"
+
+// defaultTrashRetention is the --trash-retention value used when the flag is left at its
+// default.
+const defaultTrashRetention = 24 * time.Hour
+
+// defaultPruneSafetyRatio is the --prune-safety-ratio value used when the flag is left at its
+// default: if a sync cycle would prune more than this fraction of the existing local mirror,
+// pruning is skipped entirely rather than risking a bad Drive listing wiping the mirror.
+const defaultPruneSafetyRatio = 0.5
+
+// minPruneSafetyCheckTotal is the smallest local-mirror size the safety ratio is enforced
+// against. Below it, a ratio check is meaningless (deleting the mirror's only file is always
+// "100% of the mirror"), so small directories are always allowed to prune freely.
+const minPruneSafetyCheckTotal = 10
+
+// pruneOptions configures how pruneLocalFiles (and the changes syncer's equivalent cleanup)
+// handle local paths that no longer exist on Drive.
+type pruneOptions struct {
+	useTrash       bool
+	trashRetention time.Duration
+	safetyRatio    float64
+}
+
+// trashManifestEntry records enough about a trashed file to restore it if the same content
+// reappears on Drive before the retention sweep removes it. CheckValue is the same staleness
+// signal tracked in shaCache (staleCheckValue): a content checksum for ordinary files, or a
+// modifiedTime for exported Workspace documents, which never have a checksum of their own.
+type trashManifestEntry struct {
+	RelPath    string    `json:"relPath"`
+	CheckValue string    `json:"checkValue"`
+	TrashedAt  time.Time `json:"trashedAt"`
+}
+
+func trashDir() string {
+	return filepath.Join(downloadDir, trashDirName)
+}
+
+func trashManifestPath() string {
+	return filepath.Join(trashDir(), "manifest.json")
+}
+
+func loadTrashManifest() ([]trashManifestEntry, error) {
+	data, err := os.ReadFile(trashManifestPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []trashManifestEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func saveTrashManifest(entries []trashManifestEntry) error {
+	if err := os.MkdirAll(trashDir(), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(trashManifestPath(), data, 0644)
+}
+
+// moveToTrash moves path into a timestamped folder under trashDir() instead of deleting it, and
+// records it in the manifest (keyed by its path relative to downloadDir and its staleness check
+// value) so restoreFromTrash can bring it back if Drive serves up the same content again.
+func moveToTrash(path, timestamp, checkValue string) error {
+	relPath, err := filepath.Rel(downloadDir, path)
+	if err != nil {
+		return err
+	}
+
+	dest := filepath.Join(trashDir(), timestamp, relPath)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(path, dest); err != nil {
+		return err
+	}
+
+	entries, err := loadTrashManifest()
+	if err != nil {
+		return err
+	}
+	trashedAt, err := time.Parse(trashTimestampFormat, timestamp)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, trashManifestEntry{RelPath: relPath, CheckValue: checkValue, TrashedAt: trashedAt})
+	return saveTrashManifest(entries)
+}
+
+// restoreFromTrash looks for a trashed copy of localPath whose staleness check value matches
+// checkValue and, if found, moves it back into place and drops it from the manifest. It reports
+// restored=true when that happened, so callers can skip re-downloading the file.
+func restoreFromTrash(localPath, checkValue string) (restored bool, err error) {
+	if checkValue == "" {
+		return false, nil
+	}
+	entries, err := loadTrashManifest()
+	if err != nil || len(entries) == 0 {
+		return false, err
+	}
+
+	relPath, err := filepath.Rel(downloadDir, localPath)
+	if err != nil {
+		return false, nil
+	}
+
+	remaining := entries[:0]
+	for _, entry := range entries {
+		if !restored && entry.RelPath == relPath && entry.CheckValue == checkValue {
+			src := filepath.Join(trashDir(), entry.TrashedAt.UTC().Format(trashTimestampFormat), entry.RelPath)
+			if mkdirErr := os.MkdirAll(filepath.Dir(localPath), 0755); mkdirErr != nil {
+				return false, mkdirErr
+			}
+			if renameErr := os.Rename(src, localPath); renameErr == nil {
+				restored = true
+				continue
+			}
+		}
+		remaining = append(remaining, entry)
+	}
+	if restored {
+		if err := saveTrashManifest(remaining); err != nil {
+			return true, err
+		}
+	}
+	return restored, nil
+}
+
+// sweepTrash permanently removes trash entries (and their manifest rows) older than retention.
+func sweepTrash(retention time.Duration) error {
+	entries, err := loadTrashManifest()
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+
+	cutoff := time.Now().Add(-retention)
+	var kept []trashManifestEntry
+	for _, entry := range entries {
+		if entry.TrashedAt.After(cutoff) {
+			kept = append(kept, entry)
+			continue
+		}
+		full := filepath.Join(trashDir(), entry.TrashedAt.UTC().Format(trashTimestampFormat), entry.RelPath)
+		if err := os.RemoveAll(full); err != nil {
+			log.Printf("Failed to sweep trash entry %s: %v", full, err)
+			kept = append(kept, entry)
+		}
+	}
+	return saveTrashManifest(kept)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/api/drive/v3"
+)
+
+// TestPerformSyncTrashesInsteadOfDeleting verifies that, with useTrash set, a file removed from
+// the remote is moved under downloadDir/.trash instead of being deleted outright.
+func TestPerformSyncTrashesInsteadOfDeleting(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "test-trash")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	originalDownloadDir := downloadDir
+	downloadDir = tmpDir
+	defer func() { downloadDir = originalDownloadDir }()
+
+	mockAPI := &mockDriveAPI{
+		files: map[string][]*drive.File{
+			"root_folder_id": {
+				{Id: "file1_id", Name: "file1.txt", MimeType: "text/plain", Sha256Checksum: "sha_file1"},
+				{Id: "file2_id", Name: "file2.txt", MimeType: "text/plain", Sha256Checksum: "sha_file2"},
+			},
+		},
+		folders: map[string]string{"test-folder": "root_folder_id"},
+		fileContent: map[string]string{
+			"file1_id": "content1",
+			"file2_id": "content2",
+		},
+	}
+
+	shaCache := make(map[string]string)
+	exportExts := parseExportExtensions(defaultExportExtensions)
+	opts := pruneOptions{useTrash: true, trashRetention: defaultTrashRetention, safetyRatio: defaultPruneSafetyRatio}
+
+	if _, err := performSync(context.Background(), mockAPI, "test-folder", time.Time{}, shaCache, exportExts, nil, defaultParallelDownloads, opts); err != nil {
+		t.Fatalf("performSync failed: %v", err)
+	}
+
+	// Remove file1 from the remote and sync again.
+	mockAPI.files["root_folder_id"] = []*drive.File{
+		{Id: "file2_id", Name: "file2.txt", MimeType: "text/plain", Sha256Checksum: "sha_file2"},
+	}
+	if _, err := performSync(context.Background(), mockAPI, "test-folder", time.Now(), shaCache, exportExts, nil, defaultParallelDownloads, opts); err != nil {
+		t.Fatalf("second performSync failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(tmpDir, "file1.txt")); !os.IsNotExist(err) {
+		t.Errorf("file1.txt should have been moved out of place, still present at original path")
+	}
+	if _, ok := shaCache[filepath.Join(tmpDir, "file1.txt")]; ok {
+		t.Errorf("file1.txt's SHA cache entry should have been dropped")
+	}
+
+	entries, err := loadTrashManifest()
+	if err != nil {
+		t.Fatalf("loadTrashManifest failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].RelPath != "file1.txt" || entries[0].CheckValue != "sha_file1" {
+		t.Fatalf("unexpected trash manifest: %+v", entries)
+	}
+	if _, err := os.Stat(filepath.Join(trashDir(), entries[0].TrashedAt.UTC().Format(trashTimestampFormat), "file1.txt")); err != nil {
+		t.Errorf("trashed copy of file1.txt not found on disk: %v", err)
+	}
+}
+
+// TestPerformSyncAbortsWhenPruneTooLarge verifies that a sync cycle which would delete more than
+// opts.safetyRatio of the existing local mirror fails instead of touching anything.
+func TestPerformSyncAbortsWhenPruneTooLarge(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "test-prune-guard")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	originalDownloadDir := downloadDir
+	downloadDir = tmpDir
+	defer func() { downloadDir = originalDownloadDir }()
+
+	// Seed enough files that the safety ratio is actually enforced (it's skipped below
+	// minPruneSafetyCheckTotal, since a ratio is meaningless for a handful of files).
+	const numFiles = minPruneSafetyCheckTotal + 2
+	var files []*drive.File
+	fileContent := make(map[string]string)
+	for i := 0; i < numFiles; i++ {
+		id := fmt.Sprintf("file%d_id", i)
+		name := fmt.Sprintf("file%d.txt", i)
+		files = append(files, &drive.File{Id: id, Name: name, MimeType: "text/plain", Sha256Checksum: "sha_" + id})
+		fileContent[id] = fmt.Sprintf("content-%d", i)
+	}
+
+	mockAPI := &mockDriveAPI{
+		files:       map[string][]*drive.File{"root_folder_id": files},
+		folders:     map[string]string{"test-folder": "root_folder_id"},
+		fileContent: fileContent,
+	}
+
+	shaCache := make(map[string]string)
+	exportExts := parseExportExtensions(defaultExportExtensions)
+	opts := pruneOptions{safetyRatio: defaultPruneSafetyRatio}
+
+	if _, err := performSync(context.Background(), mockAPI, "test-folder", time.Time{}, shaCache, exportExts, nil, defaultParallelDownloads, opts); err != nil {
+		t.Fatalf("performSync failed: %v", err)
+	}
+
+	// Every remote file disappears at once: pruning all of them exceeds the 50% safety ratio.
+	mockAPI.files["root_folder_id"] = nil
+	if _, err := performSync(context.Background(), mockAPI, "test-folder", time.Now(), shaCache, exportExts, nil, defaultParallelDownloads, opts); err == nil {
+		t.Fatalf("expected performSync to fail when pruning would exceed the safety ratio")
+	}
+
+	for i := 0; i < numFiles; i++ {
+		assertFileContent(t, filepath.Join(tmpDir, fmt.Sprintf("file%d.txt", i)), fmt.Sprintf("content-%d", i))
+	}
+}
+
+// TestRestoreFromTrashOnReappearance verifies that a file trashed in one cycle is moved back into
+// place, rather than re-downloaded, if it reappears on Drive with the same checksum before the
+// retention window expires.
+func TestRestoreFromTrashOnReappearance(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "test-trash-restore")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	originalDownloadDir := downloadDir
+	downloadDir = tmpDir
+	defer func() { downloadDir = originalDownloadDir }()
+
+	mockAPI := &mockDriveAPI{
+		files: map[string][]*drive.File{
+			"root_folder_id": {
+				{Id: "file1_id", Name: "file1.txt", MimeType: "text/plain", Sha256Checksum: "sha_file1"},
+			},
+		},
+		folders:     map[string]string{"test-folder": "root_folder_id"},
+		fileContent: map[string]string{"file1_id": "content1"},
+	}
+
+	shaCache := make(map[string]string)
+	exportExts := parseExportExtensions(defaultExportExtensions)
+	opts := pruneOptions{useTrash: true, trashRetention: defaultTrashRetention, safetyRatio: defaultPruneSafetyRatio}
+
+	if _, err := performSync(context.Background(), mockAPI, "test-folder", time.Time{}, shaCache, exportExts, nil, defaultParallelDownloads, opts); err != nil {
+		t.Fatalf("performSync failed: %v", err)
+	}
+
+	// Trash file1, then let it reappear on Drive (a new file ID, same content and checksum, as
+	// Drive would report after an undo-delete).
+	mockAPI.files["root_folder_id"] = nil
+	if _, err := performSync(context.Background(), mockAPI, "test-folder", time.Now(), shaCache, exportExts, nil, defaultParallelDownloads, opts); err != nil {
+		t.Fatalf("second performSync failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "file1.txt")); !os.IsNotExist(err) {
+		t.Fatalf("file1.txt should have been trashed")
+	}
+
+	mockAPI.files["root_folder_id"] = []*drive.File{
+		{Id: "file1_id_v2", Name: "file1.txt", MimeType: "text/plain", Sha256Checksum: "sha_file1"},
+	}
+	delete(mockAPI.fileContent, "file1_id")
+	if _, err := performSync(context.Background(), mockAPI, "test-folder", time.Time{}, shaCache, exportExts, nil, defaultParallelDownloads, opts); err != nil {
+		t.Fatalf("third performSync failed: %v", err)
+	}
+
+	assertFileContent(t, filepath.Join(tmpDir, "file1.txt"), "content1")
+
+	entries, err := loadTrashManifest()
+	if err != nil {
+		t.Fatalf("loadTrashManifest failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected the trash manifest entry to be consumed on restore, got %+v", entries)
+	}
+}